@@ -0,0 +1,287 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package redistest wires up multi-process Redis topologies (currently just
+// primary/replica/Sentinel) for use by this module's own tests. It's not
+// part of the redis package's public API.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instance is a single redis-server or redis-sentinel process started from a
+// generated config file.
+type Instance struct {
+	Name string
+	Addr string
+
+	binary string
+	dir    string
+	cmd    *exec.Cmd
+	done   chan struct{}
+}
+
+// Stop sends an interrupt (SIGINT) to the instance and waits for it to exit.
+func (i *Instance) Stop() {
+	if i.cmd == nil || i.cmd.Process == nil {
+		return
+	}
+	i.cmd.Process.Signal(os.Interrupt) // nolint: errcheck
+	<-i.done
+	os.RemoveAll(i.dir)
+}
+
+// Kill sends SIGKILL, simulating a hard crash instead of a clean shutdown.
+// Used by tests to trigger a Sentinel failover.
+func (i *Instance) Kill() {
+	if i.cmd == nil || i.cmd.Process == nil {
+		return
+	}
+	i.cmd.Process.Kill() // nolint: errcheck
+	<-i.done
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("freePort: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// startInstance writes conf (one directive per line) to a temp file under a
+// fresh temp dir and execs binary against it, waiting for it to report
+// readiness on stdout.
+func startInstance(name, binary string, conf []string) (*Instance, error) {
+	dir, err := os.MkdirTemp("", "redistest-"+name+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	confPath := filepath.Join(dir, name+".conf")
+	if err := os.WriteFile(confPath, []byte(strings.Join(conf, "\n")+"\n"), 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	i := &Instance{
+		Name:   name,
+		binary: binary,
+		dir:    dir,
+		cmd:    exec.Command(binary, confPath),
+		done:   make(chan struct{}),
+	}
+
+	r, err := i.cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if err := i.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	readyCh := make(chan error, 1)
+	go i.watch(r, readyCh)
+
+	select {
+	case err = <-readyCh:
+	case <-time.After(10 * time.Second):
+		err = fmt.Errorf("%s: timeout waiting for server to start", name)
+	}
+
+	if err != nil {
+		i.Stop()
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// readyPhrases are the different ways a redis-server/redis-sentinel process
+// announces it's accepting connections across versions: pre-7 logs "Ready to
+// accept connections", 7+ logs "The server is now ready to accept
+// connections on port ...". watch checks for either, since the harness
+// doesn't know the installed version ahead of time.
+var readyPhrases = []string{
+	"Ready to accept connections",
+	"The server is now ready to accept connections on port",
+}
+
+func (i *Instance) watch(r io.Reader, readyCh chan error) {
+	var listening bool
+	var text string
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		text = scn.Text()
+		if !listening {
+			for _, phrase := range readyPhrases {
+				if strings.Contains(text, phrase) {
+					listening = true
+					readyCh <- nil
+					break
+				}
+			}
+		}
+	}
+	if !listening {
+		readyCh <- fmt.Errorf("%s: exited before becoming ready: %s", i.Name, text)
+	}
+	i.cmd.Wait() // nolint: errcheck
+	close(i.done)
+}
+
+// SentinelServer is a primary, N replicas, and M sentinels wired together
+// and ready to use in tests.
+type SentinelServer struct {
+	MasterName string
+
+	Primary   *Instance
+	Replicas  []*Instance
+	Sentinels []*Instance
+}
+
+// NewSentinelServer starts a primary, numReplicas replicas of it, and
+// numSentinels sentinels monitoring masterName, using serverPath and
+// sentinelPath as the redis-server and redis-sentinel binaries.
+func NewSentinelServer(masterName, serverPath, sentinelPath string, numReplicas, numSentinels int) (*SentinelServer, error) {
+	s := &SentinelServer{MasterName: masterName}
+
+	primary, err := startPrimary(serverPath)
+	if err != nil {
+		return nil, err
+	}
+	s.Primary = primary
+
+	for i := 0; i < numReplicas; i++ {
+		replica, err := startReplica(serverPath, i, primary.Addr)
+		if err != nil {
+			s.Stop()
+			return nil, err
+		}
+		s.Replicas = append(s.Replicas, replica)
+	}
+
+	for i := 0; i < numSentinels; i++ {
+		sentinel, err := startSentinel(sentinelPath, i, masterName, primary.Addr)
+		if err != nil {
+			s.Stop()
+			return nil, err
+		}
+		s.Sentinels = append(s.Sentinels, sentinel)
+	}
+
+	return s, nil
+}
+
+func startPrimary(serverPath string) (*Instance, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	conf := []string{
+		"port " + strconv.Itoa(port),
+		"bind 127.0.0.1",
+		"save \"\"",
+		"appendonly no",
+	}
+	i, err := startInstance("primary", serverPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	i.Addr = addr
+	return i, nil
+}
+
+func startReplica(serverPath string, index int, primaryAddr string) (*Instance, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	host, primaryPort, _ := net.SplitHostPort(primaryAddr)
+	conf := []string{
+		"port " + strconv.Itoa(port),
+		"bind 127.0.0.1",
+		"save \"\"",
+		"appendonly no",
+		fmt.Sprintf("replicaof %s %s", host, primaryPort),
+	}
+	i, err := startInstance(fmt.Sprintf("replica%d", index), serverPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	i.Addr = addr
+	return i, nil
+}
+
+func startSentinel(sentinelPath string, index int, masterName, primaryAddr string) (*Instance, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	host, primaryPort, _ := net.SplitHostPort(primaryAddr)
+	conf := []string{
+		"port " + strconv.Itoa(port),
+		"bind 127.0.0.1",
+		fmt.Sprintf("sentinel monitor %s %s %s 1", masterName, host, primaryPort),
+		fmt.Sprintf("sentinel down-after-milliseconds %s 2000", masterName),
+		fmt.Sprintf("sentinel failover-timeout %s 4000", masterName),
+		fmt.Sprintf("sentinel parallel-syncs %s 1", masterName),
+	}
+	i, err := startInstance(fmt.Sprintf("sentinel%d", index), sentinelPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	i.Addr = addr
+	return i, nil
+}
+
+// SentinelAddrs returns the addresses of all sentinel processes, suitable
+// for use as the SentinelAddrs field of a redis.SentinelPool.
+func (s *SentinelServer) SentinelAddrs() []string {
+	addrs := make([]string, len(s.Sentinels))
+	for i, sentinel := range s.Sentinels {
+		addrs[i] = sentinel.Addr
+	}
+	return addrs
+}
+
+// Stop stops every process in the topology.
+func (s *SentinelServer) Stop() {
+	for _, sentinel := range s.Sentinels {
+		sentinel.Stop()
+	}
+	for _, replica := range s.Replicas {
+		replica.Stop()
+	}
+	if s.Primary != nil {
+		s.Primary.Stop()
+	}
+}
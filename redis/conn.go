@@ -0,0 +1,737 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Conn represents a connection to a Redis server.
+type Conn interface {
+	// Close closes the connection.
+	Close() error
+
+	// Err returns a non-nil value if the connection is broken. The
+	// returned value is the first error the connection hit, or a
+	// "redis: closed" error once Close has been called.
+	Err() error
+
+	// Do sends a command to the server and returns the received reply.
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+
+	// Send writes the command to the client's output buffer.
+	Send(commandName string, args ...interface{}) error
+
+	// Flush flushes the output buffer to the Redis server.
+	Flush() error
+
+	// Receive receives a single reply from the Redis server.
+	Receive() (reply interface{}, err error)
+}
+
+// Error represents an error returned in a reply from a Redis server, i.e. a
+// RESP "-" simple error.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// ErrNil indicates that a reply value is nil.
+var ErrNil = errors.New("redis: nil returned")
+
+// errNegativeInt is returned when a reply helper expects a non-negative
+// integer reply (e.g. converting an Int64 reply to Uint64) but got a
+// negative one.
+var errNegativeInt = errors.New("redis: unexpected negative value")
+
+// nowFunc returns the current time. It's a variable so tests can substitute
+// a fake clock via SetNowFunc.
+var nowFunc = time.Now
+
+// conn is the default Conn implementation used by Dial and DialContext.
+type conn struct {
+	mu  sync.Mutex
+	err error
+
+	netConn net.Conn
+	br      *bufio.Reader
+	bw      *bufio.Writer
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	lenScratch [32]byte
+	numScratch [40]byte
+
+	// protocol is the RESP protocol version negotiated with the server: 2
+	// (the default) or 3. See resp3.go.
+	protocol int
+	pushCh   chan Push
+}
+
+// NewConn returns a new Conn using netConn for I/O.
+func NewConn(netConn net.Conn, readTimeout, writeTimeout time.Duration) Conn {
+	return &conn{
+		netConn:      netConn,
+		br:           bufio.NewReader(netConn),
+		bw:           bufio.NewWriter(netConn),
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+// dialOptions accumulates the effect of a []DialOption for Dial/DialContext.
+type dialOptions struct {
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	dial         func(network, address string) (net.Conn, error)
+	dialContext  func(ctx context.Context, network, address string) (net.Conn, error)
+
+	db         int
+	username   string
+	password   string
+	clientName string
+
+	useTLS     bool
+	skipVerify bool
+	tlsConfig  *tls.Config
+
+	// protocol is set by DialProtocol, defined in resp3.go.
+	protocol int
+}
+
+// DialOption specifies an option for dialing a Redis server.
+type DialOption struct {
+	f func(*dialOptions)
+}
+
+// DialReadTimeout specifies the timeout for reading a single command reply.
+func DialReadTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) { do.readTimeout = d }}
+}
+
+// DialWriteTimeout specifies the timeout for writing a single command.
+func DialWriteTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) { do.writeTimeout = d }}
+}
+
+// DialNetDial specifies a custom dial function for creating TCP connections,
+// otherwise a net.Dialer is used.
+func DialNetDial(dial func(network, addr string) (net.Conn, error)) DialOption {
+	return DialOption{func(do *dialOptions) { do.dial = dial }}
+}
+
+// DialContextFunc specifies a custom dial function, like DialNetDial, that
+// also receives the context passed to DialContext.
+func DialContextFunc(dial func(ctx context.Context, network, addr string) (net.Conn, error)) DialOption {
+	return DialOption{func(do *dialOptions) { do.dialContext = dial }}
+}
+
+// DialDatabase specifies the database to select after connecting.
+func DialDatabase(db int) DialOption {
+	return DialOption{func(do *dialOptions) { do.db = db }}
+}
+
+// DialUsername specifies the username to authenticate with, for servers with
+// ACLs. Requires DialPassword to also be set.
+func DialUsername(username string) DialOption {
+	return DialOption{func(do *dialOptions) { do.username = username }}
+}
+
+// DialPassword specifies the password to authenticate with.
+func DialPassword(password string) DialOption {
+	return DialOption{func(do *dialOptions) { do.password = password }}
+}
+
+// DialClientName specifies a client name to be set with CLIENT SETNAME after
+// connecting.
+func DialClientName(name string) DialOption {
+	return DialOption{func(do *dialOptions) { do.clientName = name }}
+}
+
+// DialUseTLS specifies whether TLS should be used when connecting.
+func DialUseTLS(useTLS bool) DialOption {
+	return DialOption{func(do *dialOptions) { do.useTLS = useTLS }}
+}
+
+// DialTLSSkipVerify disables server certificate verification when using
+// TLS. This option is insecure and should only be used for testing.
+func DialTLSSkipVerify(skip bool) DialOption {
+	return DialOption{func(do *dialOptions) { do.skipVerify = skip }}
+}
+
+// DialTLSConfig specifies the *tls.Config to use when DialUseTLS(true) is
+// set. If no ServerName is set on it, Dial fills one in from the dial
+// address.
+func DialTLSConfig(c *tls.Config) DialOption {
+	return DialOption{func(do *dialOptions) { do.tlsConfig = c }}
+}
+
+// Dial connects to the Redis server at address on the named network.
+func Dial(network, address string, options ...DialOption) (Conn, error) {
+	return DialContext(context.Background(), network, address, options...)
+}
+
+// DialContext connects to the Redis server at address on the named network,
+// with the given context controlling the dial and the protocol handshake
+// that follows it (HELLO, AUTH, CLIENT SETNAME, SELECT).
+func DialContext(ctx context.Context, network, address string, options ...DialOption) (Conn, error) {
+	var do dialOptions
+	for _, option := range options {
+		option.f(&do)
+	}
+
+	netConn, err := dialNetConn(ctx, network, address, do)
+	if err != nil {
+		return nil, err
+	}
+
+	if do.useTLS {
+		netConn, err = wrapTLS(ctx, netConn, address, do)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := &conn{
+		netConn:      netConn,
+		br:           bufio.NewReader(netConn),
+		bw:           bufio.NewWriter(netConn),
+		readTimeout:  do.readTimeout,
+		writeTimeout: do.writeTimeout,
+		protocol:     do.protocol,
+	}
+	if do.protocol == 3 {
+		c.pushCh = make(chan Push, 100)
+	}
+
+	authDone := make(chan error, 1)
+	go func() { authDone <- authenticate(c, do) }()
+	select {
+	case err = <-authDone:
+	case <-ctx.Done():
+		c.fatal(ctx.Err())
+		<-authDone
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func dialNetConn(ctx context.Context, network, address string, do dialOptions) (net.Conn, error) {
+	switch {
+	case do.dialContext != nil:
+		return do.dialContext(ctx, network, address)
+	case do.dial != nil:
+		return do.dial(network, address)
+	default:
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+func wrapTLS(ctx context.Context, netConn net.Conn, address string, do dialOptions) (net.Conn, error) {
+	tlsConfig := do.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if do.skipVerify {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+	}
+
+	tlsConn := tls.Client(netConn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// authenticate runs the post-connect handshake: HELLO for protocol 3 (which
+// folds AUTH into the same round trip), or a plain AUTH/CLIENT SETNAME/SELECT
+// sequence for RESP2.
+func authenticate(c *conn, do dialOptions) error {
+	if do.protocol == 3 {
+		if err := helloHandshake(c, do.username, do.password); err != nil {
+			return err
+		}
+	} else if do.password != "" {
+		var err error
+		if do.username != "" {
+			_, err = c.Do("AUTH", do.username, do.password)
+		} else {
+			_, err = c.Do("AUTH", do.password)
+		}
+		if err != nil {
+			return fmt.Errorf("redis: AUTH: %w", err)
+		}
+	}
+
+	if do.clientName != "" {
+		if _, err := c.Do("CLIENT", "SETNAME", do.clientName); err != nil {
+			return fmt.Errorf("redis: CLIENT SETNAME: %w", err)
+		}
+	}
+
+	if do.db != 0 {
+		if _, err := c.Do("SELECT", do.db); err != nil {
+			return fmt.Errorf("redis: SELECT: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DoContext is like Conn.Do but cancels the command when ctx is done. It
+// falls back to a plain Do for Conn implementations other than the one
+// returned by Dial/DialContext.
+func DoContext(c Conn, ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cc, ok := c.(*conn); ok {
+		return cc.doContext(ctx, commandName, args...)
+	}
+	return c.Do(commandName, args...)
+}
+
+func (c *conn) doContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	type result struct {
+		reply interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := c.Do(commandName, args...)
+		done <- result{reply, err}
+	}()
+	select {
+	case r := <-done:
+		return r.reply, r.err
+	case <-ctx.Done():
+		c.fatal(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+func (c *conn) fatal(err error) error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.netConn.Close()
+	return err
+}
+
+func (c *conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *conn) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("redis: closed")
+	}
+	pushCh := c.pushCh
+	c.pushCh = nil
+	c.mu.Unlock()
+	if pushCh != nil {
+		close(pushCh)
+	}
+	return c.netConn.Close()
+}
+
+func (c *conn) Send(commandName string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.writeCommand(commandName, args); err != nil {
+		c.err = err
+		c.netConn.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *conn) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if c.writeTimeout != 0 {
+		c.netConn.SetWriteDeadline(nowFunc().Add(c.writeTimeout)) // nolint: errcheck
+	}
+	if err := c.bw.Flush(); err != nil {
+		c.err = err
+		c.netConn.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	if commandName != "" {
+		if err := c.writeCommand(commandName, args); err != nil {
+			c.mu.Unlock()
+			return nil, c.fatal(err)
+		}
+	}
+	if c.writeTimeout != 0 {
+		c.netConn.SetWriteDeadline(nowFunc().Add(c.writeTimeout)) // nolint: errcheck
+	}
+	if err := c.bw.Flush(); err != nil {
+		c.mu.Unlock()
+		return nil, c.fatal(err)
+	}
+	c.mu.Unlock()
+
+	return c.Receive()
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	if c.readTimeout != 0 {
+		c.netConn.SetReadDeadline(nowFunc().Add(c.readTimeout)) // nolint: errcheck
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, c.fatal(err)
+	}
+	if e, ok := reply.(Error); ok {
+		return reply, e
+	}
+	return reply, nil
+}
+
+// Push implements PushReceiver. It returns nil for a connection that wasn't
+// dialed with DialProtocol(3).
+func (c *conn) Push() <-chan Push {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pushCh
+}
+
+// deliverPush hands an out-of-band RESP3 push message to the connection's
+// Push channel. readReply calls it for every push it reads while a caller
+// of Do or Receive waits for a reply, so a push is never returned as that
+// reply, which is what keeps push messages from corrupting reply framing
+// on a pipelined connection.
+func (c *conn) deliverPush(p Push) {
+	c.mu.Lock()
+	ch := c.pushCh
+	c.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- p:
+	default:
+		// The consumer isn't keeping up; drop rather than block the
+		// caller of Do or Receive that is waiting for an ordinary reply.
+	}
+}
+
+// readReply reads replies off the wire until it gets one that isn't a RESP3
+// push message, routing any pushes it sees along the way to deliverPush.
+func (c *conn) readReply() (interface{}, error) {
+	for {
+		reply, err := c.readOne()
+		if err != nil {
+			return nil, err
+		}
+		if push, ok := reply.(Push); ok {
+			c.deliverPush(push)
+			continue
+		}
+		return reply, nil
+	}
+}
+
+// readOne reads and parses a single RESP frame, without push interception.
+// It's used both by readReply at the top level and for nested elements of
+// array/map/set replies, which never themselves carry out-of-band pushes.
+func (c *conn) readOne() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: short response line")
+	}
+	return c.parseReply(line[0], line[1:])
+}
+
+func (c *conn) readLine() ([]byte, error) {
+	p, err := c.br.ReadSlice('\n')
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			return nil, errors.New("redis: long response line")
+		}
+		return nil, err
+	}
+	i := len(p) - 2
+	if i < 0 || p[i] != '\r' {
+		return nil, errors.New("redis: bad response line terminator")
+	}
+	return p[:i], nil
+}
+
+func (c *conn) parseReply(prefix byte, payload []byte) (interface{}, error) {
+	switch prefix {
+	case '+':
+		return string(payload), nil
+	case '-':
+		return Error(string(payload)), nil
+	case ':':
+		return parseInt(payload)
+	case '$':
+		return c.readBulk(payload)
+	case '*':
+		return c.readArray(payload)
+	case resp3Double:
+		return strconv.ParseFloat(string(payload), 64)
+	case resp3Null:
+		return nil, nil
+	case resp3Boolean:
+		return len(payload) > 0 && payload[0] == 't', nil
+	case resp3VerbatimString:
+		return c.readVerbatimString(payload)
+	case resp3BigNumber:
+		return parseRESP3BigNumber(payload)
+	case resp3Map:
+		return c.readMap(payload)
+	case resp3Set:
+		return c.readArray(payload)
+	case resp3Push:
+		elems, err := c.readArray(payload)
+		if err != nil {
+			return nil, err
+		}
+		arr, _ := elems.([]interface{})
+		p := Push{Kind: toString(firstElement(arr))}
+		if len(arr) > 0 {
+			p.Data = arr[1:]
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type prefix %q", prefix)
+	}
+}
+
+func firstElement(arr []interface{}) interface{} {
+	if len(arr) == 0 {
+		return nil
+	}
+	return arr[0]
+}
+
+func parseInt(p []byte) (int64, error) {
+	return strconv.ParseInt(string(p), 10, 64)
+}
+
+func parseLen(p []byte) (int, error) {
+	n, err := strconv.Atoi(string(p))
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *conn) readBulk(payload []byte) (interface{}, error) {
+	n, err := parseLen(payload)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, nil
+	}
+	p := make([]byte, n+2)
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return nil, err
+	}
+	return p[:n], nil
+}
+
+func (c *conn) readVerbatimString(payload []byte) (interface{}, error) {
+	v, err := c.readBulk(payload)
+	if err != nil || v == nil {
+		return v, err
+	}
+	p := v.([]byte)
+	if len(p) >= 4 && p[3] == ':' {
+		return p[4:], nil
+	}
+	return p, nil
+}
+
+func (c *conn) readArray(payload []byte) (interface{}, error) {
+	n, err := parseLen(payload)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, nil
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		if arr[i], err = c.readOne(); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+// readMap reads a RESP3 map reply ('%'), whose length is a count of
+// key/value pairs, and flattens it to an alternating []interface{} so it
+// decodes with ScanStruct the same way a RESP2 HGETALL array does.
+func (c *conn) readMap(payload []byte) (interface{}, error) {
+	n, err := parseLen(payload)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, nil
+	}
+	arr := make([]interface{}, n*2)
+	for i := range arr {
+		if arr[i], err = c.readOne(); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+func (c *conn) writeLen(prefix byte, n int) error {
+	c.lenScratch[len(c.lenScratch)-1] = '\n'
+	c.lenScratch[len(c.lenScratch)-2] = '\r'
+	i := len(c.lenScratch) - 3
+	if n == 0 {
+		c.lenScratch[i] = '0'
+		i--
+	} else {
+		for n > 0 {
+			c.lenScratch[i] = byte('0' + n%10)
+			i--
+			n /= 10
+		}
+	}
+	c.lenScratch[i] = prefix
+	_, err := c.bw.Write(c.lenScratch[i:])
+	return err
+}
+
+func (c *conn) writeString(s string) error {
+	if err := c.writeLen('$', len(s)); err != nil {
+		return err
+	}
+	if _, err := c.bw.WriteString(s); err != nil {
+		return err
+	}
+	_, err := c.bw.WriteString("\r\n")
+	return err
+}
+
+func (c *conn) writeBytes(p []byte) error {
+	if err := c.writeLen('$', len(p)); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(p); err != nil {
+		return err
+	}
+	_, err := c.bw.WriteString("\r\n")
+	return err
+}
+
+func (c *conn) writeInt64(n int64) error {
+	return c.writeBytes(strconv.AppendInt(c.numScratch[:0], n, 10))
+}
+
+func (c *conn) writeFloat64(n float64) error {
+	return c.writeBytes(strconv.AppendFloat(c.numScratch[:0], n, 'g', -1, 64))
+}
+
+func (c *conn) writeArg(arg interface{}) error {
+	switch arg := arg.(type) {
+	case string:
+		return c.writeString(arg)
+	case []byte:
+		return c.writeBytes(arg)
+	case int:
+		return c.writeInt64(int64(arg))
+	case int64:
+		return c.writeInt64(arg)
+	case float64:
+		return c.writeFloat64(arg)
+	case bool:
+		if arg {
+			return c.writeString("1")
+		}
+		return c.writeString("0")
+	case nil:
+		return c.writeString("")
+	case fmt.Stringer:
+		return c.writeString(arg.String())
+	default:
+		var buf bytes.Buffer
+		fmt.Fprint(&buf, arg)
+		return c.writeBytes(buf.Bytes())
+	}
+}
+
+func (c *conn) writeCommand(cmd string, args []interface{}) error {
+	if err := c.writeLen('*', 1+len(args)); err != nil {
+		return err
+	}
+	if err := c.writeString(cmd); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := c.writeArg(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// This file is package redis_test, not redis, because it imports redistest,
+// which itself imports redis: an internal (package redis) test file can't
+// import anything that imports redis back, or the test binary becomes an
+// import cycle.
+package redis_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ankitm123/redigo/redistest"
+)
+
+// TestMain parses the -redis-server/-redis-mode/... flags registered by
+// redistest, wires up -redis-log if given, and stops any default servers
+// redistest started on the way out.
+func TestMain(m *testing.M) {
+	os.Exit(func() int {
+		flag.Parse()
+
+		if logName := redistest.LogFileFlag(); logName != "" {
+			f, err := os.OpenFile(logName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening redis-log: %v\n", err)
+				return 1
+			}
+			defer f.Close()
+			redistest.SetLogOutput(f)
+		}
+
+		defer redistest.Cleanup()
+
+		return m.Run()
+	}())
+}
@@ -0,0 +1,225 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Pool maintains a pool of connections built by Dial.
+type Pool struct {
+	// Dial creates a new connection. It's called by the pool whenever a new
+	// connection is needed and there are fewer than MaxActive already open.
+	Dial func() (Conn, error)
+
+	// TestOnBorrow, if set, is called before a pooled connection is
+	// returned by Get. If it returns an error, the connection is closed
+	// and Get tries again.
+	TestOnBorrow func(c Conn, t time.Time) error
+
+	// MaxIdle is the maximum number of idle connections kept in the pool.
+	// Zero means no limit.
+	MaxIdle int
+
+	// MaxActive is the maximum number of connections allocated at once,
+	// idle or in use. Zero means no limit.
+	MaxActive int
+
+	// IdleTimeout closes idle connections older than this after they're
+	// returned to the pool. Zero means connections are never closed due to
+	// age.
+	IdleTimeout time.Duration
+
+	// Wait, if true, makes Get block until a connection is available
+	// instead of returning an error when MaxActive connections are already
+	// allocated.
+	Wait bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	active int
+	idle   []idleConn
+}
+
+type idleConn struct {
+	c Conn
+	t time.Time
+}
+
+// Get returns a connection from the pool, dialing a new one if needed. The
+// connection must be closed after use, which returns it to the pool instead
+// of tearing it down, unless the connection is in an error state.
+//
+// Get never returns a nil Conn. If no connection can be obtained, it
+// returns a Conn whose methods all report the underlying error.
+func (p *Pool) Get() Conn {
+	c, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return c
+}
+
+// GetContext is like Get, but returns an error instead of an errorConn, and
+// gives up once ctx is done.
+func (p *Pool) GetContext(ctx context.Context) (Conn, error) {
+	for {
+		p.mu.Lock()
+
+		if p.closed {
+			p.mu.Unlock()
+			return nil, errors.New("redis: get on closed pool")
+		}
+
+		if n := len(p.idle); n > 0 {
+			ic := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+
+			if p.IdleTimeout > 0 && nowFunc().Sub(ic.t) > p.IdleTimeout {
+				ic.c.Close()
+				p.mu.Lock()
+				p.active--
+				p.mu.Unlock()
+				continue
+			}
+			if p.TestOnBorrow != nil {
+				if err := p.TestOnBorrow(ic.c, ic.t); err != nil {
+					ic.c.Close()
+					p.mu.Lock()
+					p.active--
+					p.mu.Unlock()
+					continue
+				}
+			}
+			return &pooledConn{Conn: ic.c, p: p}, nil
+		}
+
+		if p.MaxActive == 0 || p.active < p.MaxActive {
+			p.active++
+			p.mu.Unlock()
+
+			if err := ctx.Err(); err != nil {
+				p.release(nil, false)
+				return nil, err
+			}
+			dial := p.Dial
+			c, err := dial()
+			if err != nil {
+				p.release(nil, false)
+				return nil, err
+			}
+			return &pooledConn{Conn: c, p: p}, nil
+		}
+
+		if !p.Wait {
+			p.mu.Unlock()
+			return nil, errors.New("redis: connection pool exhausted")
+		}
+
+		if p.cond == nil {
+			p.cond = sync.NewCond(&p.mu)
+		}
+		waitDone := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				p.cond.Broadcast()
+			case <-waitDone:
+			}
+		}()
+		p.cond.Wait()
+		close(waitDone)
+		p.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release returns a checked-out slot to the pool, closing c unless keep is
+// true and there's room for it in the idle list. c may be nil when the slot
+// being released never had a connection dialed into it.
+func (p *Pool) release(c Conn, keep bool) {
+	p.mu.Lock()
+	if keep && c != nil && !p.closed && c.Err() == nil &&
+		(p.MaxIdle == 0 || len(p.idle) < p.MaxIdle) {
+		p.idle = append(p.idle, idleConn{c: c, t: nowFunc()})
+		if p.cond != nil {
+			p.cond.Signal()
+		}
+		p.mu.Unlock()
+		return
+	}
+	p.active--
+	if p.cond != nil {
+		p.cond.Signal()
+	}
+	p.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// Close closes the pool, including all idle connections. Connections
+// currently checked out are closed as they're returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.active -= len(idle)
+	if p.cond != nil {
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+
+	for _, ic := range idle {
+		ic.c.Close()
+	}
+	return nil
+}
+
+// pooledConn wraps a connection checked out of a Pool so that Close returns
+// it to the pool instead of tearing it down.
+type pooledConn struct {
+	Conn
+	p *Pool
+}
+
+func (pc *pooledConn) Close() error {
+	pc.p.release(pc.Conn, pc.Conn.Err() == nil)
+	return nil
+}
+
+// errorConn is a Conn whose every method returns err. Pool.Get returns one
+// instead of a nil Conn when it can't obtain a real connection.
+type errorConn struct{ err error }
+
+func (ec errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, ec.err }
+func (ec errorConn) Send(string, ...interface{}) error              { return ec.err }
+func (ec errorConn) Err() error                                     { return ec.err }
+func (ec errorConn) Close() error                                   { return nil }
+func (ec errorConn) Flush() error                                   { return ec.err }
+func (ec errorConn) Receive() (interface{}, error)                  { return nil, ec.err }
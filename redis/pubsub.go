@@ -0,0 +1,118 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import "fmt"
+
+// PubSubConn wraps a Conn with convenience methods for subscriber mode.
+type PubSubConn struct {
+	Conn Conn
+}
+
+// Subscribe subscribes to the given channels.
+func (c PubSubConn) Subscribe(channel ...interface{}) error {
+	if err := c.Conn.Send("SUBSCRIBE", channel...); err != nil {
+		return err
+	}
+	return c.Conn.Flush()
+}
+
+// PSubscribe subscribes to the given patterns.
+func (c PubSubConn) PSubscribe(channel ...interface{}) error {
+	if err := c.Conn.Send("PSUBSCRIBE", channel...); err != nil {
+		return err
+	}
+	return c.Conn.Flush()
+}
+
+// Unsubscribe unsubscribes from the given channels, or from all channels if
+// none are given.
+func (c PubSubConn) Unsubscribe(channel ...interface{}) error {
+	if err := c.Conn.Send("UNSUBSCRIBE", channel...); err != nil {
+		return err
+	}
+	return c.Conn.Flush()
+}
+
+// PUnsubscribe unsubscribes from the given patterns, or from all patterns if
+// none are given.
+func (c PubSubConn) PUnsubscribe(channel ...interface{}) error {
+	if err := c.Conn.Send("PUNSUBSCRIBE", channel...); err != nil {
+		return err
+	}
+	return c.Conn.Flush()
+}
+
+// Close closes the connection.
+func (c PubSubConn) Close() error {
+	return c.Conn.Close()
+}
+
+// Message is a message received on a subscribed channel.
+type Message struct {
+	Channel string
+	Pattern string
+	Data    []byte
+}
+
+// Subscription is a confirmation reply received in response to Subscribe,
+// Unsubscribe, PSubscribe, or PUnsubscribe.
+type Subscription struct {
+	Kind    string // "subscribe", "unsubscribe", "psubscribe", or "punsubscribe"
+	Channel string
+	Count   int
+}
+
+// Receive reads a single reply from the server and returns either a
+// Message, a Subscription, or an error.
+func (c PubSubConn) Receive() interface{} {
+	reply, err := c.Conn.Receive()
+	if err != nil {
+		return err
+	}
+	return parsePushMessage(reply)
+}
+
+func parsePushMessage(reply interface{}) interface{} {
+	arr, ok := reply.([]interface{})
+	if !ok || len(arr) < 2 {
+		return fmt.Errorf("redis: unexpected pubsub reply %v", reply)
+	}
+
+	kind := toString(arr[0])
+	switch kind {
+	case "message":
+		if len(arr) != 3 {
+			return fmt.Errorf("redis: unexpected message reply %v", reply)
+		}
+		return Message{Channel: toString(arr[1]), Data: toBytes(arr[2])}
+
+	case "pmessage":
+		if len(arr) != 4 {
+			return fmt.Errorf("redis: unexpected pmessage reply %v", reply)
+		}
+		return Message{Pattern: toString(arr[1]), Channel: toString(arr[2]), Data: toBytes(arr[3])}
+
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe":
+		if len(arr) != 3 {
+			return fmt.Errorf("redis: unexpected %s reply %v", kind, reply)
+		}
+		count, _ := arr[2].(int64)
+		return Subscription{Kind: kind, Channel: toString(arr[1]), Count: int(count)}
+
+	default:
+		return fmt.Errorf("redis: unknown pubsub notification %q", kind)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import "fmt"
+
+// Int64 converts an integer reply to int64. A nil reply gives ErrNil, an
+// error reply is returned as the error, and any other type is an error.
+func Int64(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		return reply, nil
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redis: unexpected type %T for Int64", reply)
+}
+
+// Uint64 converts a reply to uint64. It's an error for the underlying
+// integer reply to be negative, since Redis itself never returns a negative
+// value for the counters and sizes this is normally used on.
+func Uint64(reply interface{}, err error) (uint64, error) {
+	n, err := Int64(reply, err)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errNegativeInt
+	}
+	return uint64(n), nil
+}
+
+// String converts a reply to a string.
+func String(reply interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch reply := reply.(type) {
+	case []byte:
+		return string(reply), nil
+	case string:
+		return reply, nil
+	case nil:
+		return "", ErrNil
+	case Error:
+		return "", reply
+	}
+	return "", fmt.Errorf("redis: unexpected type %T for String", reply)
+}
+
+// Strings converts an array reply to a []string.
+func Strings(reply interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch reply := reply.(type) {
+	case []interface{}:
+		result := make([]string, len(reply))
+		for i, v := range reply {
+			s, err := String(v, nil)
+			if err != nil {
+				return nil, fmt.Errorf("redis: Strings element %d: %w", i, err)
+			}
+			result[i] = s
+		}
+		return result, nil
+	case nil:
+		return nil, ErrNil
+	case Error:
+		return nil, reply
+	}
+	return nil, fmt.Errorf("redis: unexpected type %T for Strings", reply)
+}
+
+// toString converts a reply element that's either a []byte or a string (and
+// tolerates other scalar types via fmt.Sprint) to a string. It's used for
+// decoding pubsub and push message headers, which may arrive as either
+// depending on protocol version.
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toBytes is toString's counterpart for message payloads.
+func toBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+)
+
+// RESP3 type prefixes. RESP2 only ever used '+', '-', ':', '$', and '*'; a
+// connection that negotiated protocol 3 via HELLO may also see any of these.
+const (
+	resp3Double         = ','
+	resp3Null           = '_'
+	resp3Boolean        = '#'
+	resp3VerbatimString = '='
+	resp3BigNumber      = '('
+	resp3Map            = '%'
+	resp3Set            = '~'
+	resp3Push           = '>'
+)
+
+// DialProtocol selects the RESP protocol version to negotiate with the
+// server. Protocol must be 2 (the default) or 3. Requesting 3 makes Dial
+// issue a HELLO handshake on connect and switches the connection's reply
+// parser (in conn.go) to recognize the RESP3 type prefixes above and route
+// out-of-band push messages to the channel returned by Push(); RESP2
+// callers that don't pass this option are unaffected.
+func DialProtocol(protocol int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.protocol = protocol
+	}}
+}
+
+// helloHandshake issues HELLO on a freshly dialed connection to switch it to
+// protocol 3, authenticating in the same round trip when credentials were
+// supplied via DialUsername/DialPassword. Dial calls this after the TCP (or
+// TLS) connection is established whenever DialProtocol(3) was given.
+func helloHandshake(c Conn, username, password string) error {
+	args := []interface{}{3}
+	switch {
+	case username != "":
+		args = append(args, "AUTH", username, password)
+	case password != "":
+		args = append(args, "AUTH", "default", password)
+	}
+	if _, err := c.Do("HELLO", args...); err != nil {
+		return fmt.Errorf("redis: HELLO handshake: %w", err)
+	}
+	return nil
+}
+
+// Push is an out-of-band message sent by the server on a RESP3 connection,
+// such as a client-side caching invalidation, a keyspace notification, or a
+// sharded pubsub message. It's delivered on the channel returned by a
+// PushReceiver instead of being returned from Do or Receive, so it can't
+// corrupt reply framing on a pipelined connection.
+type Push struct {
+	Kind string        // e.g. "invalidate", "message", "smessage"
+	Data []interface{} // remaining elements of the push array
+}
+
+// PushReceiver is implemented by the connections returned by Dial and
+// DialContext. Only one dialed with DialProtocol(3) has a push channel; for
+// any other, Push returns nil.
+type PushReceiver interface {
+	// Push returns the channel that out-of-band RESP3 push messages are
+	// delivered on. The channel is closed when the connection is closed.
+	Push() <-chan Push
+}
+
+// parseRESP3BigNumber parses a RESP3 big number reply, i.e. the digits
+// following a '(' type prefix. Redigo represents it as a decimal string
+// since it may exceed the range of int64.
+func parseRESP3BigNumber(line []byte) (string, error) {
+	if len(line) == 0 {
+		return "", fmt.Errorf("redis: empty big number reply")
+	}
+	for i, b := range line {
+		if b >= '0' && b <= '9' {
+			continue
+		}
+		if i == 0 && (b == '-' || b == '+') {
+			continue
+		}
+		return "", fmt.Errorf("redis: invalid big number reply %q", line)
+	}
+	return string(line), nil
+}
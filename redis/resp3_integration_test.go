@@ -0,0 +1,77 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// This file is package redis_test, not redis, for the same reason
+// main_test.go is: it imports redistest, which imports redis, and an
+// internal (package redis) test file can't import anything that imports
+// redis back without the toolchain flagging an import cycle.
+package redis_test
+
+import (
+	"testing"
+
+	"github.com/ankitm123/redigo/redis"
+	"github.com/ankitm123/redigo/redistest"
+)
+
+// TestDialProtocol3 checks the RESP3 handshake and map decoding end to end:
+// HELLO negotiates protocol 3, a RESP3 map reply (HGETALL on a RESP3
+// connection) round-trips through ScanStruct, and the connection still
+// implements PushReceiver even though this test doesn't trigger a push.
+func TestDialProtocol3(t *testing.T) {
+	s, err := redistest.NewServerOnFreePort("resp3")
+	if err != nil {
+		t.Skipf("no redis-server or embedded backend available: %v", err)
+	}
+	defer s.Stop()
+
+	addr, err := s.Addr()
+	if err != nil {
+		t.Fatalf("Addr: %v", err)
+	}
+
+	c, err := redis.Dial("tcp", addr, redis.DialProtocol(3))
+	if err != nil {
+		t.Fatalf("Dial with DialProtocol(3): %v", err)
+	}
+	defer c.Close()
+
+	pr, ok := c.(redis.PushReceiver)
+	if !ok {
+		t.Fatal("connection dialed with DialProtocol(3) doesn't implement PushReceiver")
+	}
+	if pr.Push() == nil {
+		t.Error("Push() = nil, want a non-nil channel for a protocol 3 connection")
+	}
+
+	if _, err := c.Do("HSET", "item", "name", "widget", "count", "3"); err != nil {
+		t.Fatalf("HSET: %v", err)
+	}
+
+	reply, err := c.Do("HGETALL", "item")
+	if err != nil {
+		t.Fatalf("HGETALL: %v", err)
+	}
+
+	var item struct {
+		Name  string `redis:"name"`
+		Count int    `redis:"count"`
+	}
+	if err := redis.ScanStruct(reply, &item); err != nil {
+		t.Fatalf("ScanStruct: %v", err)
+	}
+	if item.Name != "widget" || item.Count != 3 {
+		t.Errorf("ScanStruct result = %+v, want {Name:widget Count:3}", item)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import "testing"
+
+func TestParseRESP3BigNumber(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "3492890328409238509324850943850943825024385", want: "3492890328409238509324850943850943825024385"},
+		{in: "-3492890328409238509324850943850943825024385", want: "-3492890328409238509324850943850943825024385"},
+		{in: "", wantErr: true},
+		{in: "12x34", wantErr: true},
+	} {
+		got, err := parseRESP3BigNumber([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRESP3BigNumber(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parseRESP3BigNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ScanStruct decodes a flattened key/value array reply into dest, a pointer
+// to a struct. Both a RESP2 HGETALL-style array and the RESP3 map reply
+// that conn.go flattens to the same []interface{} shape work unchanged.
+//
+// Struct fields are matched against array keys using the `redis` tag,
+// falling back to the field name, compared case-insensitively; keys with no
+// matching field are ignored, and fields with no matching key are left at
+// their zero value.
+func ScanStruct(src interface{}, dest interface{}) error {
+	arr, ok := src.([]interface{})
+	if !ok {
+		return fmt.Errorf("redis: ScanStruct: unexpected type %T for source", src)
+	}
+	if len(arr)%2 != 0 {
+		return fmt.Errorf("redis: ScanStruct: reply has an odd number of elements")
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("redis: ScanStruct: dest must be a non-nil pointer to a struct, got %T", dest)
+	}
+	sv := dv.Elem()
+	st := sv.Type()
+
+	fieldIndex := make(map[string]int, st.NumField())
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		name := f.Tag.Get("redis")
+		if name == "" {
+			name = f.Name
+		}
+		fieldIndex[strings.ToLower(name)] = i
+	}
+
+	for i := 0; i+1 < len(arr); i += 2 {
+		key := strings.ToLower(toString(arr[i]))
+		fi, ok := fieldIndex[key]
+		if !ok {
+			continue
+		}
+		if err := scanValue(sv.Field(fi), arr[i+1]); err != nil {
+			return fmt.Errorf("redis: ScanStruct: field %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// scanInt64 converts src to an int64, following Int64's rules for an actual
+// integer reply but also accepting a bulk string/[]byte, since hash field
+// values (the usual source of a map-typed reply) always come back as bulk
+// strings regardless of protocol version.
+func scanInt64(src interface{}) (int64, error) {
+	if n, err := Int64(src, nil); err == nil {
+		return n, nil
+	}
+	s, err := String(src, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected type %T for integer field", src)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// scanValue assigns src, a single reply element, to fv, a settable struct
+// field, converting it the same way the Int64/String/... reply helpers do.
+func scanValue(fv reflect.Value, src interface{}) error {
+	switch fv.Kind() {
+	case reflect.String:
+		s, err := String(src, nil)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported field type %s", fv.Type())
+		}
+		s, err := String(src, nil)
+		if err != nil {
+			return err
+		}
+		fv.SetBytes([]byte(s))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := scanInt64(src)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := scanInt64(src)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errNegativeInt
+		}
+		fv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		s, err := String(src, nil)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("redis: ScanStruct: %w", err)
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		s, err := String(src, nil)
+		if err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("redis: ScanStruct: %w", err)
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import "testing"
+
+func TestScanStruct(t *testing.T) {
+	type item struct {
+		Name  string `redis:"name"`
+		Count int    `redis:"count"`
+		Price float64
+		OK    bool
+	}
+
+	src := []interface{}{
+		"name", "widget",
+		"count", int64(3),
+		"price", "2.5",
+		"ok", "1",
+		"unknown", "ignored",
+	}
+
+	var got item
+	if err := ScanStruct(src, &got); err != nil {
+		t.Fatalf("ScanStruct: %v", err)
+	}
+	want := item{Name: "widget", Count: 3, Price: 2.5, OK: true}
+	if got != want {
+		t.Errorf("ScanStruct result = %+v, want %+v", got, want)
+	}
+}
+
+func TestScanStructErrors(t *testing.T) {
+	var dest struct{ Name string }
+
+	if err := ScanStruct([]interface{}{"name"}, &dest); err == nil {
+		t.Error("ScanStruct with odd-length reply: want error, got nil")
+	}
+	if err := ScanStruct("not a slice", &dest); err == nil {
+		t.Error("ScanStruct with non-array reply: want error, got nil")
+	}
+	if err := ScanStruct([]interface{}{"name", "x"}, dest); err == nil {
+		t.Error("ScanStruct with non-pointer dest: want error, got nil")
+	}
+}
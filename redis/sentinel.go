@@ -0,0 +1,301 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// watchSwitchMasterRetryDelay bounds how fast watchSwitchMaster retries
+// after failing to dial or subscribe to every address in SentinelAddrs, so a
+// transient outage against all of them doesn't spin hot.
+const watchSwitchMasterRetryDelay = time.Second
+
+// ErrNoSentinels is returned by SentinelPool when none of the configured
+// sentinel addresses could be reached or answered with the master's address.
+var ErrNoSentinels = errors.New("redis: no sentinel could be reached")
+
+// SentinelPool is a Pool that discovers the current Redis master through a
+// set of Sentinel addresses instead of a fixed address, and automatically
+// follows failovers announced over the "+switch-master" pubsub channel.
+//
+// SentinelPool implements the same Get/GetContext interface as Pool, backed
+// internally by a *Pool dialing whatever address was last resolved.
+type SentinelPool struct {
+	// MasterName is the name of the monitored master, as configured on the
+	// sentinels (the name passed to "sentinel monitor <name> ...").
+	MasterName string
+
+	// SentinelAddrs is the list of sentinel addresses to query and
+	// subscribe to. At least one must be reachable.
+	SentinelAddrs []string
+
+	// Dial dials a connection to a resolved master or sentinel address.
+	// If nil, DefaultDial is used.
+	Dial func(addr string) (Conn, error)
+
+	// NewPool builds the underlying *Pool for a resolved master address.
+	// If nil, NewDefaultPool is used.
+	NewPool func(dial func() (Conn, error)) *Pool
+
+	mu         sync.Mutex
+	pool       *Pool
+	masterAddr string
+	watching   bool
+	watchConn  Conn
+	closed     bool
+}
+
+// DefaultDial dials addr with the standard Dial function. It's the default
+// used by SentinelPool.Dial.
+func DefaultDial(addr string) (Conn, error) {
+	return Dial("tcp", addr)
+}
+
+// NewDefaultPool builds a *Pool with sane defaults for a SentinelPool's
+// resolved master. It's the default used by SentinelPool.NewPool.
+func NewDefaultPool(dial func() (Conn, error)) *Pool {
+	return &Pool{Dial: dial}
+}
+
+func (sp *SentinelPool) dial(addr string) (Conn, error) {
+	if sp.Dial != nil {
+		return sp.Dial(addr)
+	}
+	return DefaultDial(addr)
+}
+
+func (sp *SentinelPool) newPool(dial func() (Conn, error)) *Pool {
+	if sp.NewPool != nil {
+		return sp.NewPool(dial)
+	}
+	return NewDefaultPool(dial)
+}
+
+// queryMaster asks each sentinel in turn for the current master address,
+// returning the first one that answers.
+func (sp *SentinelPool) queryMaster() (string, error) {
+	var lastErr error
+	for _, addr := range sp.SentinelAddrs {
+		c, err := sp.dial(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		reply, err := Strings(c.Do("SENTINEL", "get-master-addr-by-name", sp.MasterName))
+		c.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(reply) != 2 {
+			lastErr = fmt.Errorf("redis: unexpected SENTINEL get-master-addr-by-name reply %v", reply)
+			continue
+		}
+		return fmt.Sprintf("%s:%s", reply[0], reply[1]), nil
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoSentinels, lastErr)
+	}
+	return "", ErrNoSentinels
+}
+
+// resolvedPool returns the underlying *Pool for the current master, resolving it
+// (and starting the failover watcher) on first use.
+func (sp *SentinelPool) resolvedPool() (*Pool, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.closed {
+		return nil, errors.New("redis: SentinelPool closed")
+	}
+	if sp.pool != nil {
+		return sp.pool, nil
+	}
+
+	addr, err := sp.queryMaster()
+	if err != nil {
+		return nil, err
+	}
+
+	sp.masterAddr = addr
+	sp.pool = sp.newPool(func() (Conn, error) { return sp.dial(addr) })
+
+	if !sp.watching {
+		sp.watching = true
+		go sp.watchSwitchMaster()
+	}
+
+	return sp.pool, nil
+}
+
+// watchSwitchMaster subscribes to +switch-master on the first reachable
+// sentinel and invalidates the cached pool whenever our master is announced
+// as having moved, so the next Get resolves and dials the new master. It
+// keeps retrying, reconnecting to the (possibly different) first reachable
+// sentinel, until SentinelPool is closed, and clears sp.watching only once
+// that happens.
+func (sp *SentinelPool) watchSwitchMaster() {
+	for {
+		if sp.isClosed() {
+			break
+		}
+		if !sp.watchOnce() {
+			time.Sleep(watchSwitchMasterRetryDelay)
+		}
+	}
+
+	sp.mu.Lock()
+	sp.watching = false
+	sp.mu.Unlock()
+}
+
+func (sp *SentinelPool) isClosed() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.closed
+}
+
+// watchOnce dials the first reachable sentinel in SentinelAddrs, subscribes
+// to +switch-master on it, and processes messages until the subscription
+// breaks or Close unblocks it. It reports whether it managed to subscribe at
+// all, so watchSwitchMaster knows whether to back off before retrying.
+func (sp *SentinelPool) watchOnce() bool {
+	for _, addr := range sp.SentinelAddrs {
+		c, err := sp.dial(addr)
+		if err != nil {
+			continue
+		}
+
+		psc := PubSubConn{Conn: c}
+		if err := psc.Subscribe("+switch-master"); err != nil {
+			c.Close()
+			continue
+		}
+
+		sp.mu.Lock()
+		if sp.closed {
+			sp.mu.Unlock()
+			c.Close()
+			return true
+		}
+		sp.watchConn = c
+		sp.mu.Unlock()
+
+		sp.receiveSwitchMaster(psc)
+
+		sp.mu.Lock()
+		sp.watchConn = nil
+		sp.mu.Unlock()
+		c.Close()
+		return true
+	}
+	return false
+}
+
+// receiveSwitchMaster reads messages off psc, invalidating the cached pool
+// whenever our master is announced as having moved, until psc errors out
+// (the connection dropped, or Close closed sp.watchConn to unblock it).
+func (sp *SentinelPool) receiveSwitchMaster(psc PubSubConn) {
+	for {
+		switch n := psc.Receive().(type) {
+		case Message:
+			fields := splitSpaces(string(n.Data))
+			if len(fields) > 0 && fields[0] == sp.MasterName {
+				sp.invalidate()
+			}
+		case error:
+			return
+		}
+	}
+}
+
+// invalidate drops the cached pool so the next Get re-resolves the master.
+func (sp *SentinelPool) invalidate() {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.pool != nil {
+		sp.pool.Close()
+		sp.pool = nil
+	}
+}
+
+func splitSpaces(s string) []string {
+	var fields []string
+	start := -1
+	for i, r := range s {
+		if r == ' ' {
+			if start >= 0 {
+				fields = append(fields, s[start:i])
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, s[start:])
+	}
+	return fields
+}
+
+// Get returns a connection to the current master, resolving it through
+// Sentinel if necessary. The connection must be closed after use.
+//
+// Get never returns a nil Conn. If no master can be resolved, it returns a
+// Conn whose methods all report the underlying error; callers that want the
+// error directly should use GetContext instead.
+func (sp *SentinelPool) Get() Conn {
+	c, err := sp.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return c
+}
+
+// GetContext returns a connection to the current master, resolving it
+// through Sentinel if necessary.
+func (sp *SentinelPool) GetContext(ctx context.Context) (Conn, error) {
+	pool, err := sp.resolvedPool()
+	if err != nil {
+		return nil, err
+	}
+	return pool.GetContext(ctx)
+}
+
+// Close closes the underlying pool and stops following failovers. It also
+// closes the pubsub connection the failover watcher is blocked reading on,
+// if any, so that goroutine exits instead of leaking.
+func (sp *SentinelPool) Close() error {
+	sp.mu.Lock()
+	sp.closed = true
+	watchConn := sp.watchConn
+	sp.watchConn = nil
+	pool := sp.pool
+	sp.pool = nil
+	sp.mu.Unlock()
+
+	if watchConn != nil {
+		watchConn.Close()
+	}
+	if pool != nil {
+		return pool.Close()
+	}
+	return nil
+}
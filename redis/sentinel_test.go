@@ -0,0 +1,86 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ankitm123/redigo/internal/redistest"
+	"github.com/ankitm123/redigo/redis"
+)
+
+func requireSentinelBinaries(t *testing.T) (serverPath, sentinelPath string) {
+	t.Helper()
+	serverPath, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server not found in PATH")
+	}
+	sentinelPath, err = exec.LookPath("redis-sentinel")
+	if err != nil {
+		t.Skip("redis-sentinel not found in PATH")
+	}
+	return serverPath, sentinelPath
+}
+
+func TestSentinelPoolFailover(t *testing.T) {
+	serverPath, sentinelPath := requireSentinelBinaries(t)
+
+	const masterName = "mymaster"
+
+	topo, err := redistest.NewSentinelServer(masterName, serverPath, sentinelPath, 1, 3)
+	if err != nil {
+		t.Fatalf("NewSentinelServer: %v", err)
+	}
+	defer topo.Stop()
+
+	pool := &redis.SentinelPool{
+		MasterName:    masterName,
+		SentinelAddrs: topo.SentinelAddrs(),
+	}
+	defer pool.Close()
+
+	c, err := pool.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	if _, err := c.Do("SET", "foo", "bar"); err != nil {
+		t.Fatalf("SET: %v", err)
+	}
+	c.Close()
+
+	// Kill the primary so Sentinel promotes a replica and announces
+	// +switch-master.
+	topo.Primary.Kill()
+
+	deadline := time.Now().Add(30 * time.Second)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		c, err := pool.GetContext(context.Background())
+		if err == nil {
+			_, lastErr = c.Do("PING")
+			c.Close()
+			if lastErr == nil {
+				return
+			}
+		} else {
+			lastErr = err
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	t.Fatalf("pool did not reconnect to promoted replica: %v", lastErr)
+}
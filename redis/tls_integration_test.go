@@ -0,0 +1,131 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ankitm123/redigo/redis"
+	"github.com/ankitm123/redigo/redistest"
+)
+
+// freePortForTest asks the kernel for a currently unused TCP port, the same
+// way redistest.NewServerOnFreePort does internally; this file doesn't need
+// a full Server, just a port to hand to ServerConfig.
+func freePortForTest(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freePortForTest: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func requireRedisServerBinary(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("redis-server"); err != nil {
+		t.Skip("redis-server not found in PATH")
+	}
+}
+
+// TestDialDefaultTLSServer checks that DialDefaultTLSServer actually
+// establishes a working TLS connection, not just one that dials without
+// error: the self-signed certificate it generates must be one the server
+// accepts and the client trusts, and ordinary commands must round-trip over
+// it.
+func TestDialDefaultTLSServer(t *testing.T) {
+	requireRedisServerBinary(t)
+
+	c, err := redistest.DialDefaultTLSServer()
+	if err != nil {
+		t.Fatalf("DialDefaultTLSServer: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "foo", "bar"); err != nil {
+		t.Fatalf("SET: %v", err)
+	}
+	got, err := redis.String(c.Do("GET", "foo"))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	if got != "bar" {
+		t.Fatalf("GET = %q, want %q", got, "bar")
+	}
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("PING: %v", err)
+	}
+}
+
+// TestServerConfigDirectives checks that a server started from ServerConfig
+// actually applies the directives it's documented to set, not just that the
+// process starts: an ACL user defined via ACLFile must be able to AUTH, and
+// ClusterEnabled must turn on cluster mode.
+func TestServerConfigDirectives(t *testing.T) {
+	requireRedisServerBinary(t)
+
+	dir, err := os.MkdirTemp("", "redigo-acl-")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	aclFile := filepath.Join(dir, "users.acl")
+	const aclLine = "user tester on >s3cret ~* &* +@all\n"
+	if err := os.WriteFile(aclFile, []byte(aclLine), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	port := freePortForTest(t)
+
+	s, err := redistest.NewServerConfig("acl-cluster", redistest.ServerConfig{
+		Port:           port,
+		BindAddr:       "127.0.0.1",
+		ACLFile:        aclFile,
+		ClusterEnabled: true,
+		ExtraDirectives: map[string]string{
+			"cluster-config-file": filepath.Join(dir, "nodes.conf"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewServerConfig: %v", err)
+	}
+	defer s.Stop()
+
+	addr, err := s.Addr()
+	if err != nil {
+		t.Fatalf("Addr: %v", err)
+	}
+
+	c, err := redis.Dial("tcp", addr, redis.DialUsername("tester"), redis.DialPassword("s3cret"))
+	if err != nil {
+		t.Fatalf("Dial with ACL user: %v", err)
+	}
+	defer c.Close()
+
+	info, err := redis.String(c.Do("CLUSTER", "INFO"))
+	if err != nil {
+		t.Fatalf("CLUSTER INFO: %v", err)
+	}
+	if !strings.Contains(info, "cluster_enabled:1") {
+		t.Fatalf("CLUSTER INFO = %q, want cluster_enabled:1", info)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerConfig describes a redis-server instance to start from a generated
+// config file, which is how NewServer starts its process backend. Pass one
+// directly to NewServerConfig, instead of going through NewServer, for
+// features the embedded backend can't provide (TLS, ACLs, modules, cluster
+// mode), since that forces the real redis-server binary regardless of
+// -redis-mode.
+type ServerConfig struct {
+	Port     int
+	BindAddr string
+
+	TLSPort     int
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSCAFile   string
+
+	ACLFile        string
+	ClusterEnabled bool
+	Modules        []string
+
+	// ExtraDirectives are appended verbatim as "key value" lines, for
+	// anything not covered by a named field above.
+	ExtraDirectives map[string]string
+}
+
+func (cfg *ServerConfig) directives() []string {
+	var lines []string
+	if cfg.BindAddr != "" {
+		lines = append(lines, "bind "+cfg.BindAddr)
+	}
+	if cfg.Port != 0 {
+		lines = append(lines, "port "+strconv.Itoa(cfg.Port))
+	}
+	if cfg.TLSPort != 0 {
+		lines = append(lines,
+			"tls-port "+strconv.Itoa(cfg.TLSPort),
+			"tls-cert-file "+cfg.TLSCertFile,
+			"tls-key-file "+cfg.TLSKeyFile)
+		if cfg.TLSCAFile != "" {
+			lines = append(lines, "tls-ca-cert-file "+cfg.TLSCAFile)
+		}
+	}
+	if cfg.ACLFile != "" {
+		lines = append(lines, "aclfile "+cfg.ACLFile)
+	}
+	if cfg.ClusterEnabled {
+		lines = append(lines, "cluster-enabled yes")
+	}
+	for _, module := range cfg.Modules {
+		lines = append(lines, "loadmodule "+module)
+	}
+	for k, v := range cfg.ExtraDirectives {
+		lines = append(lines, k+" "+v)
+	}
+	lines = append(lines, "save \"\"", "appendonly no")
+	return lines
+}
+
+func (cfg *ServerConfig) addr() (string, error) {
+	bind := cfg.BindAddr
+	if bind == "" {
+		bind = *serverAddress
+	}
+	switch {
+	case cfg.Port != 0:
+		return fmt.Sprintf("%s:%d", bind, cfg.Port), nil
+	case cfg.TLSPort != 0:
+		return fmt.Sprintf("%s:%d", bind, cfg.TLSPort), nil
+	default:
+		return "", errors.New("redis: ServerConfig has neither Port nor TLSPort set")
+	}
+}
+
+// NewServerConfig starts a redis-server instance named name from a generated
+// config file built from cfg. The temp directory holding that file is
+// removed when Stop is called.
+func NewServerConfig(name string, cfg ServerConfig) (*Server, error) {
+	// Subtest names contain '/', which MkdirTemp rejects in a pattern.
+	dir, err := os.MkdirTemp("", "redigo-"+strings.ReplaceAll(name, "/", "_")+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	confPath := filepath.Join(dir, "redis.conf")
+	conf := strings.Join(cfg.directives(), "\n") + "\n"
+	if err := os.WriteFile(confPath, []byte(conf), 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	c := &configServer{
+		name: name,
+		dir:  dir,
+		cmd:  exec.Command(*serverPath, confPath),
+		done: make(chan struct{}),
+	}
+
+	r, err := c.cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if err := c.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	ready := make(chan error, 1)
+	go c.watch(r, ready)
+
+	select {
+	case err = <-ready:
+	case <-time.After(10 * time.Second):
+		err = errors.New("timeout waiting for server to start")
+	}
+
+	if err != nil {
+		c.Stop()
+		return nil, err
+	}
+
+	s := &Server{name: name, backend: c}
+	if addr, addrErr := cfg.addr(); addrErr == nil {
+		s.addr = addr
+	}
+	return s, nil
+}
+
+// configServer runs redis-server against a generated config file and
+// removes the temp config directory on Stop.
+type configServer struct {
+	name string
+	dir  string
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+func (c *configServer) watch(r io.Reader, ready chan error) {
+	fmt.Fprintf(serverLog, "%d START %s \n", c.cmd.Process.Pid, c.name)
+	var listening bool
+	var text string
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		text = scn.Text()
+		fmt.Fprintf(serverLog, "%s\n", text)
+		if !listening {
+			if strings.Contains(text, " * Ready to accept connections") ||
+				strings.Contains(text, " * The server is now ready to accept connections on port") {
+				listening = true
+				ready <- nil
+			}
+		}
+	}
+	if !listening {
+		ready <- fmt.Errorf("server exited: %s", text)
+	}
+	if err := c.cmd.Wait(); err != nil {
+		if listening {
+			ready <- err
+		}
+	}
+	fmt.Fprintf(serverLog, "%d STOP %s \n", c.cmd.Process.Pid, c.name)
+	close(c.done)
+}
+
+func (c *configServer) Stop() {
+	c.cmd.Process.Signal(os.Interrupt) // nolint: errcheck
+	<-c.done
+	os.RemoveAll(c.dir)
+}
@@ -0,0 +1,58 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build !no_miniredis
+
+package redistest
+
+import (
+	"fmt"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// embeddedServer runs an in-process miniredis instance in place of a real
+// redis-server binary. This is the default -redis-mode=auto fallback, so
+// tests work out of the box on a box without redis-server installed; build
+// or test with "-tags no_miniredis" to exclude the
+// github.com/alicebob/miniredis/v2 dependency instead.
+type embeddedServer struct {
+	name string
+	mini *miniredis.Miniredis
+}
+
+// newEmbeddedServer starts a miniredis instance bound to cfg's BindAddr/Port,
+// so it's a drop-in replacement for newProcessServer from the caller's point
+// of view. cfg's other fields (TLS, ACL, cluster mode, ...) aren't supported
+// by miniredis and are ignored.
+func newEmbeddedServer(name string, cfg ServerConfig) (*Server, error) {
+	addr, err := cfg.addr()
+	if err != nil {
+		return nil, fmt.Errorf("embedded server: %w", err)
+	}
+
+	m := miniredis.NewMiniRedis()
+	if err := m.StartAddr(addr); err != nil {
+		return nil, fmt.Errorf("embedded server: %w", err)
+	}
+
+	fmt.Fprintf(serverLog, "EMBEDDED START %s %s\n", name, addr)
+
+	return &Server{name: name, addr: addr, backend: &embeddedServer{name: name, mini: m}}, nil
+}
+
+func (e *embeddedServer) Stop() {
+	e.mini.Close()
+	fmt.Fprintf(serverLog, "EMBEDDED STOP %s\n", e.name)
+}
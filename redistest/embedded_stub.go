@@ -0,0 +1,28 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build no_miniredis
+
+package redistest
+
+import "fmt"
+
+// newEmbeddedServer is a stub used when this binary was built with the
+// no_miniredis tag, which keeps the github.com/alicebob/miniredis/v2
+// dependency out of binaries that don't want it. Without that tag,
+// embedded.go provides a real embedded backend instead of this stub, so
+// -redis-mode=auto works without redis-server installed.
+func newEmbeddedServer(name string, cfg ServerConfig) (*Server, error) {
+	return nil, fmt.Errorf("embedded redis server requested but this binary was built with the %q tag", "no_miniredis")
+}
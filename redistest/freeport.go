@@ -0,0 +1,102 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankitm123/redigo/redis"
+)
+
+// freePortRetries bounds how many times NewServerOnFreePort retries after
+// losing the TOCTOU race between freePort closing its probe listener and the
+// server actually binding the port it reported.
+const freePortRetries = 5
+
+// freePort asks the kernel for a currently unused TCP port on 127.0.0.1 by
+// binding to port 0, reading back the port it was assigned, and closing the
+// listener again. There's a small window between that close and whatever
+// binds the port next, so callers that hit EADDRINUSE should retry.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("freePort: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// NewServerOnFreePort starts a Redis instance named name bound to a port
+// freePort reports as available, retrying on EADDRINUSE to ride out the
+// TOCTOU window between freePort and the server actually binding.
+func NewServerOnFreePort(name string) (*Server, error) {
+	var lastErr error
+	for i := 0; i < freePortRetries; i++ {
+		port, err := freePort()
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := NewServer(name, ServerConfig{
+			Port:     port,
+			BindAddr: *serverAddress,
+		})
+		if err == nil {
+			return s, nil
+		}
+		if !isAddrInUse(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("NewServerOnFreePort: giving up after %d attempts: %w", freePortRetries, lastErr)
+}
+
+// isAddrInUse reports whether err looks like the server failed to start
+// because the port freePort handed out was grabbed by someone else first.
+// Real redis-server logs capitalize this message; the miniredis-embedded
+// backend and net.Listen report it lowercase, so match case-insensitively.
+func isAddrInUse(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "address already in use")
+}
+
+// DialNewServer starts a dedicated Server for tb, registers tb.Cleanup to
+// stop it, and dials a connection to it. Unlike DialDefaultServer, each
+// caller gets its own isolated instance, so tests using it are safe to run
+// with t.Parallel().
+func DialNewServer(tb testing.TB, options ...redis.DialOption) redis.Conn {
+	tb.Helper()
+
+	s, err := NewServerOnFreePort(tb.Name())
+	if err != nil {
+		tb.Fatalf("DialNewServer: %v", err)
+	}
+	tb.Cleanup(s.Stop)
+
+	addr, err := s.Addr()
+	if err != nil {
+		tb.Fatalf("DialNewServer: %v", err)
+	}
+
+	c, err := redis.Dial("tcp", addr, append([]redis.DialOption{redis.DialReadTimeout(1 * time.Second), redis.DialWriteTimeout(1 * time.Second)}, options...)...)
+	if err != nil {
+		tb.Fatalf("DialNewServer: %v", err)
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ankitm123/redigo/redis"
+)
+
+// TestDialNewServerParallel checks that two parallel subtests, each starting
+// a server with NewServerOnFreePort (the helper DialNewServer is built on),
+// get their own isolated instance and don't interfere with each other, which
+// is the whole point of the freeport helpers over DialDefaultServer's single
+// shared instance.
+func TestDialNewServerParallel(t *testing.T) {
+	for _, key := range []string{"a", "b"} {
+		key := key
+		t.Run(key, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := NewServerOnFreePort(t.Name())
+			if err != nil {
+				t.Skipf("no redis-server or embedded backend available: %v", err)
+			}
+			t.Cleanup(s.Stop)
+
+			addr, err := s.Addr()
+			if err != nil {
+				t.Fatalf("Addr: %v", err)
+			}
+			c, err := redis.Dial("tcp", addr, redis.DialReadTimeout(time.Second), redis.DialWriteTimeout(time.Second))
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+			defer c.Close()
+
+			if _, err := c.Do("SET", "key", key); err != nil {
+				t.Fatalf("SET: %v", err)
+			}
+			got, err := redis.String(c.Do("GET", "key"))
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			if got != key {
+				t.Fatalf("GET returned %q, want %q (another test's server leaked in)", got, key)
+			}
+		})
+	}
+}
+
+// TestIsAddrInUse checks the matching is case-insensitive, since real
+// redis-server and the miniredis-embedded backend don't agree on casing.
+func TestIsAddrInUse(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("listen tcp 127.0.0.1:0: bind: Address already in use"), true},
+		{fmt.Errorf("listen tcp 127.0.0.1:0: bind: address already in use"), true},
+		{fmt.Errorf("connection refused"), false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := isAddrInUse(c.err); got != c.want {
+			t.Errorf("isAddrInUse(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+type version struct {
+	major int
+	minor int
+	patch int
+}
+
+func redisServerVersion() (*version, error) {
+	out, err := exec.Command(*serverPath, "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("server version: %w", err)
+	}
+
+	ver := string(out)
+	re := regexp.MustCompile(`v=(\d+)\.(\d+)\.(\d+)`)
+	match := re.FindStringSubmatch(ver)
+	if len(match) != 4 {
+		return nil, fmt.Errorf("no server version found in %q", ver)
+	}
+
+	var v version
+	if v.major, err = strconv.Atoi(match[1]); err != nil {
+		return nil, fmt.Errorf("invalid major version %q", match[1])
+	}
+
+	if v.minor, err = strconv.Atoi(match[2]); err != nil {
+		return nil, fmt.Errorf("invalid minor version %q", match[2])
+	}
+
+	if v.patch, err = strconv.Atoi(match[3]); err != nil {
+		return nil, fmt.Errorf("invalid patch version %q", match[3])
+	}
+
+	return &v, nil
+}
+
+// newProcessServer starts a real redis-server instance named name from cfg
+// the same way NewServerConfig does, adding an "enable-debug-command local"
+// directive for Redis 7+ (needed by some commands the test suite uses, and
+// only available from that version on).
+func newProcessServer(name string, cfg ServerConfig) (*Server, error) {
+	v, err := redisServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if v.major >= 7 {
+		extra := make(map[string]string, len(cfg.ExtraDirectives)+1)
+		for k, val := range cfg.ExtraDirectives {
+			extra[k] = val
+		}
+		extra["enable-debug-command"] = "local"
+		cfg.ExtraDirectives = extra
+	}
+
+	return NewServerConfig(name, cfg)
+}
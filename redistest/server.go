@@ -0,0 +1,171 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package redistest launches real or embedded Redis instances for use in
+// tests, both this module's own and downstream consumers'. Unlike
+// internal/redistest (which wires up multi-process Sentinel topologies for
+// this module's own test suite and can't be imported from outside it),
+// redistest is a regular importable package specifically so that code
+// embedding redigo can spin up a Server in its own tests without depending
+// on redigo's _test.go files, which the Go toolchain excludes from normal
+// builds.
+package redistest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/ankitm123/redigo/redis"
+)
+
+var (
+	serverPath     = flag.String("redis-server", "redis-server", "Path to redis server binary")
+	serverAddress  = flag.String("redis-address", "127.0.0.1", "The address of the server")
+	serverBasePort = flag.Int("redis-port", 16379, "Beginning of port range for test servers")
+	serverLogName  = flag.String("redis-log", "", "Write Redis server logs to `filename`")
+	serverMode     = flag.String("redis-mode", "auto", "Test server backend: auto, process, or embedded")
+	serverLog      io.Writer = ioutil.Discard
+
+	defaultServerMu  sync.Mutex
+	defaultServer    *Server
+	defaultServerErr error
+)
+
+// SetLogOutput redirects the server's stdout logging (normally discarded)
+// to w. Typically wired up to a -redis-log flag alongside flag.Parse().
+func SetLogOutput(w io.Writer) {
+	serverLog = w
+}
+
+// LogFileFlag returns the filename given via -redis-log, or "" if unset.
+// Callers that want to honor -redis-log should open the file themselves and
+// pass it to SetLogOutput, since redistest doesn't own process lifetime.
+func LogFileFlag() string {
+	return *serverLogName
+}
+
+// Server manages a Redis instance used by tests. Depending on -redis-mode,
+// the instance is either a real redis-server process or an in-process
+// embedded server, but callers see the same type either way.
+type Server struct {
+	name    string
+	addr    string
+	backend serverBackend
+}
+
+// Addr returns the address Server is listening on, if known. It's known
+// whenever the server's ServerConfig set Port or TLSPort.
+func (s *Server) Addr() (string, error) {
+	if s.addr == "" {
+		return "", fmt.Errorf("%s: server has no known address (started without Port or TLSPort)", s.name)
+	}
+	return s.addr, nil
+}
+
+// serverBackend is implemented by the concrete process or embedded server
+// types. It lets Server stay agnostic of how the instance is actually run.
+type serverBackend interface {
+	Stop()
+}
+
+// NewServer starts a Redis instance named name from cfg. Depending on
+// -redis-mode it either execs *serverPath against a generated config file or,
+// when that binary can't be found (or -redis-mode=embedded was requested),
+// falls back to an in-process embedded server with equivalent Stop()
+// semantics. The embedded backend only supports cfg's BindAddr and Port;
+// TLS, ACLs, modules, and cluster mode require -redis-mode=process (or
+// NewServerConfig directly, which always uses the real binary).
+func NewServer(name string, cfg ServerConfig) (*Server, error) {
+	switch *serverMode {
+	case "process":
+		return newProcessServer(name, cfg)
+	case "embedded":
+		return newEmbeddedServer(name, cfg)
+	case "auto":
+		if _, err := exec.LookPath(*serverPath); err != nil {
+			return newEmbeddedServer(name, cfg)
+		}
+		return newProcessServer(name, cfg)
+	default:
+		return nil, fmt.Errorf("unknown -redis-mode %q", *serverMode)
+	}
+}
+
+func (s *Server) Stop() {
+	s.backend.Stop()
+}
+
+// stopDefaultServer stops the server created by DialDefaultServer.
+func stopDefaultServer() {
+	defaultServerMu.Lock()
+	defer defaultServerMu.Unlock()
+	if defaultServer != nil {
+		defaultServer.Stop()
+		defaultServer = nil
+	}
+}
+
+// DefaultServerAddr starts the default test server if not already started
+// and returns the address of that server.
+func DefaultServerAddr() (string, error) {
+	defaultServerMu.Lock()
+	defer defaultServerMu.Unlock()
+	addr := fmt.Sprintf("%v:%d", *serverAddress, *serverBasePort)
+	if defaultServer != nil || defaultServerErr != nil {
+		return addr, defaultServerErr
+	}
+	defaultServer, defaultServerErr = NewServer("default", ServerConfig{
+		Port:     *serverBasePort,
+		BindAddr: *serverAddress,
+	})
+	return addr, defaultServerErr
+}
+
+// DialDefaultServer starts the default test server if not already started
+// and dials a connection to it.
+func DialDefaultServer(options ...redis.DialOption) (redis.Conn, error) {
+	return DialDefaultServerContext(context.Background(), options...)
+}
+
+// DialDefaultServerContext starts the default test server if not already
+// started and dials a connection to it with the given context.
+func DialDefaultServerContext(ctx context.Context, options ...redis.DialOption) (redis.Conn, error) {
+	addr, err := DefaultServerAddr()
+	if err != nil {
+		return nil, err
+	}
+	c, err := redis.DialContext(ctx, "tcp", addr, append([]redis.DialOption{redis.DialReadTimeout(1 * time.Second), redis.DialWriteTimeout(1 * time.Second)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = redis.DoContext(c, ctx, "FLUSHDB"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Cleanup stops any default servers started by DefaultServerAddr,
+// DialDefaultServer, or DialDefaultTLSServer. Call it from a TestMain that
+// uses those, so leftover processes don't linger after the test binary
+// exits.
+func Cleanup() {
+	stopDefaultServer()
+	stopDefaultTLSServer()
+}
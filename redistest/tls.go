@@ -0,0 +1,208 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ankitm123/redigo/redis"
+)
+
+var (
+	defaultTLSServerMu   sync.Mutex
+	defaultTLSServer     *Server
+	defaultTLSServerAddr string
+	defaultTLSServerCA   string
+	defaultTLSServerErr  error
+)
+
+// stopDefaultTLSServer stops the server created by DialDefaultTLSServer.
+func stopDefaultTLSServer() {
+	defaultTLSServerMu.Lock()
+	defer defaultTLSServerMu.Unlock()
+	if defaultTLSServer != nil {
+		defaultTLSServer.Stop()
+		defaultTLSServer = nil
+	}
+}
+
+// defaultTLSServerAddrAndCA starts the default TLS test server, generating
+// its self-signed certificate, if not already started.
+func defaultTLSServerAddrAndCA() (addr, caFile string, err error) {
+	defaultTLSServerMu.Lock()
+	defer defaultTLSServerMu.Unlock()
+	if defaultTLSServer != nil || defaultTLSServerErr != nil {
+		return defaultTLSServerAddr, defaultTLSServerCA, defaultTLSServerErr
+	}
+
+	dir, err := os.MkdirTemp("", "redigo-tls-cert-")
+	if err != nil {
+		defaultTLSServerErr = err
+		return "", "", err
+	}
+
+	certFile, keyFile, err := generateSelfSignedCert(dir, *serverAddress)
+	if err != nil {
+		defaultTLSServerErr = err
+		return "", "", err
+	}
+
+	port, err := freePort()
+	if err != nil {
+		defaultTLSServerErr = err
+		return "", "", err
+	}
+
+	s, err := NewServerConfig("default-tls", ServerConfig{
+		BindAddr:    *serverAddress,
+		TLSPort:     port,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+		TLSCAFile:   certFile, // self-signed: the leaf cert is also the CA.
+		ExtraDirectives: map[string]string{
+			"port":             "0",
+			"tls-auth-clients": "no",
+		},
+	})
+	if err != nil {
+		defaultTLSServerErr = err
+		return "", "", err
+	}
+
+	defaultTLSServer = s
+	defaultTLSServerAddr = fmt.Sprintf("%s:%d", *serverAddress, port)
+	defaultTLSServerCA = certFile
+	return defaultTLSServerAddr, defaultTLSServerCA, nil
+}
+
+// DialDefaultTLSServer starts the default TLS test server if not already
+// started, generating a self-signed certificate on first use, and dials a
+// connection to it over TLS.
+func DialDefaultTLSServer(options ...redis.DialOption) (redis.Conn, error) {
+	addr, caFile, err := defaultTLSServerAddrAndCA()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsConfigTrusting(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append([]redis.DialOption{
+		redis.DialUseTLS(true),
+		redis.DialTLSConfig(tlsConfig),
+		redis.DialReadTimeout(1 * time.Second),
+		redis.DialWriteTimeout(1 * time.Second),
+	}, options...)
+
+	c, err := redis.Dial("tcp", addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = redis.DoContext(c, context.Background(), "FLUSHDB"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// tlsConfigTrusting builds a *tls.Config that trusts only the certificate at
+// certFile, for dialing a server using a self-signed cert generated by
+// generateSelfSignedCert.
+func tlsConfigTrusting(certFile string) (*tls.Config, error) {
+	pemBytes, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("redis: no certificates found in %s", certFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// generateSelfSignedCert writes a self-signed ECDSA certificate and key,
+// valid for host, to cert.pem and key.pem under dir.
+func generateSelfSignedCert(dir, host string) (certFile, keyFile string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	if err := writePEM(certFile, "CERTIFICATE", der); err != nil {
+		return "", "", err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyBytes); err != nil {
+		return "", "", err
+	}
+
+	return certFile, keyFile, nil
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+}